@@ -0,0 +1,187 @@
+package progsort
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSortContext(t *testing.T) {
+	const N = 100_000
+	items := rand.Perm(N)
+	swapped, err := SortContext(context.Background(), items, nil, func(a, b int) bool { return a < b })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped {
+		t.Fatal("items should hold the final sorted data")
+	}
+	if !sort.IntsAreSorted(items) {
+		t.Fatal("not sorted")
+	}
+}
+
+func TestSortContextCanceled(t *testing.T) {
+	const N = 2_000_000
+	items := rand.Perm(N)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Microsecond)
+	defer cancel()
+	_, err := SortContext(ctx, items, nil, func(a, b int) bool { return a < b })
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSortContextWithWorkers(t *testing.T) {
+	const N = 50_000
+	items := rand.Perm(N)
+	swapped, err := SortContext(context.Background(), items, nil,
+		func(a, b int) bool { return a < b }, WithWorkers(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped {
+		t.Fatal("items should hold the final sorted data")
+	}
+	if !sort.IntsAreSorted(items) {
+		t.Fatal("not sorted")
+	}
+}
+
+func TestSortContextWithBaseRun(t *testing.T) {
+	for _, algo := range []BaseSort{InsertionSort, PDQSort} {
+		for _, n := range []int{0, 1, 31, 32, 33, 1_000, 12_345} {
+			items := rand.Perm(n)
+			swapped, err := SortContext(context.Background(), items, nil,
+				func(a, b int) bool { return a < b }, WithBaseRun(32, algo))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if swapped {
+				t.Fatal("items should hold the final sorted data")
+			}
+			if !sort.IntsAreSorted(items) {
+				t.Fatalf("algo=%v n=%d: not sorted", algo, n)
+			}
+		}
+	}
+}
+
+func TestSortContextWithBaseRunFunc(t *testing.T) {
+	const N = 10_000
+	items := rand.Perm(N)
+	var calls int32
+	fn := func(data []int, less func(a, b int) bool) {
+		atomic.AddInt32(&calls, 1)
+		sort.Slice(data, func(i, j int) bool { return less(data[i], data[j]) })
+	}
+	swapped, err := SortContext(context.Background(), items, nil,
+		func(a, b int) bool { return a < b }, WithBaseRunFunc(40, fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped {
+		t.Fatal("items should hold the final sorted data")
+	}
+	if !sort.IntsAreSorted(items) {
+		t.Fatal("not sorted")
+	}
+	if calls == 0 {
+		t.Fatal("expected the custom base-run func to be called")
+	}
+}
+
+func TestSortContextWithProg(t *testing.T) {
+	const N = 50_000
+	items := rand.Perm(N)
+	var last Progress
+	var calls int
+	swapped, err := SortContext(context.Background(), items, nil,
+		func(a, b int) bool { return a < b },
+		WithProg(func(p Progress) bool {
+			calls++
+			if p.Percent < last.Percent {
+				t.Fatal("percent out of order")
+			}
+			last = p
+			return true
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped {
+		t.Fatal("items should hold the final sorted data")
+	}
+	if calls == 0 {
+		t.Fatal("expected at least one progress callback")
+	}
+	if last.Percent != 1 {
+		t.Fatalf("expected final percent of 1, got %v", last.Percent)
+	}
+	if !sort.IntsAreSorted(items) {
+		t.Fatal("not sorted")
+	}
+}
+
+func TestSortContextWithProgCancel(t *testing.T) {
+	const N = 500_000
+	items := rand.Perm(N)
+	_, err := SortContext(context.Background(), items, nil,
+		func(a, b int) bool { return a < b },
+		WithProg(func(p Progress) bool { return false }))
+	if err != ErrCanceled {
+		t.Fatalf("expected ErrCanceled, got %v", err)
+	}
+}
+
+func TestSortContextWithBaseRunAndProg(t *testing.T) {
+	const N = 100_000
+	items := rand.Perm(N)
+	var last Progress
+	swapped, err := SortContext(context.Background(), items, nil,
+		func(a, b int) bool { return a < b },
+		WithBaseRun(32, PDQSort),
+		WithProg(func(p Progress) bool {
+			if p.Percent < last.Percent {
+				t.Fatal("percent out of order")
+			}
+			last = p
+			return true
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swapped {
+		t.Fatal("items should hold the final sorted data")
+	}
+	if last.Percent != 1 {
+		t.Fatalf("expected final percent of 1, got %v", last.Percent)
+	}
+	if !sort.IntsAreSorted(items) {
+		t.Fatal("not sorted")
+	}
+}
+
+func TestSortContextWithPool(t *testing.T) {
+	pool := newChanPool(4)
+	defer pool.closeAndWait()
+
+	for i := 0; i < 3; i++ {
+		const N = 20_000
+		items := rand.Perm(N)
+		swapped, err := SortContext(context.Background(), items, nil,
+			func(a, b int) bool { return a < b }, WithPool(pool))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if swapped {
+			t.Fatal("items should hold the final sorted data")
+		}
+		if !sort.IntsAreSorted(items) {
+			t.Fatal("not sorted")
+		}
+	}
+}
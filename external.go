@@ -0,0 +1,424 @@
+// Copyright (c) 2022, Joshua J Baker. All rights reserved.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package progsort
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrCanceled is returned by SortFile and SortReader when the prog
+// callback returns false, requesting early cancellation.
+var ErrCanceled = errors.New("progsort: canceled")
+
+const (
+	defaultMemBudget = 256 * 1024 * 1024
+	defaultFanIn     = 64
+)
+
+// SortFile performs an external (disk-backed) merge sort of the
+// fixed-size, elsize-byte records stored in the file at path, for
+// datasets too large to sort entirely in memory.
+//
+// The workDir param specifies the directory used for the temporary run
+// files created during the sort. An empty workDir uses the directory
+// containing path.
+//
+// The memBudget param is the approximate number of bytes read into memory
+// at a time while generating sorted runs. A memBudget <= 0 defaults to
+// 256 MiB.
+//
+// The fanIn param is the maximum number of runs merged together at a
+// time during the merge phase; when more runs than that are generated,
+// extra passes are used. A fanIn < 2 defaults to 64.
+//
+// The prog function can be optionally provided to monitor progress across
+// both the run-generation and merge phases, weighted by bytes processed so
+// it stays monotonic, same as Sort and SortBytes. Set prog to nil if
+// progress monitoring is not needed. Returning false from prog cancels the
+// sort early, in which case SortFile returns ErrCanceled and removes any
+// temporary files it created, leaving the original file untouched.
+func SortFile(
+	path string,
+	elsize int,
+	less func(a, b []byte) bool,
+	workDir string,
+	memBudget int,
+	fanIn int,
+	prog func(perc float64) bool,
+) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if workDir == "" {
+		workDir = filepath.Dir(path)
+	}
+	out, err := os.CreateTemp(workDir, filepath.Base(path)+"-sorted-*")
+	if err != nil {
+		return err
+	}
+	outPath := out.Name()
+	defer os.Remove(outPath)
+
+	if err := SortReader(in, out, elsize, less, workDir, memBudget, fanIn, prog); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := in.Close(); err != nil {
+		return err
+	}
+	return os.Rename(outPath, path)
+}
+
+// SortReader performs an external (disk-backed) merge sort of the
+// fixed-size, elsize-byte records read from r, writing the sorted records
+// to w. It's used internally by SortFile, and exposed directly for
+// callers that want to sort between arbitrary streams, such as keeping
+// the unsorted and sorted data in separate files.
+//
+// See SortFile for a description of workDir, memBudget, fanIn, and prog,
+// except that an empty workDir uses os.TempDir().
+func SortReader(
+	r io.Reader,
+	w io.Writer,
+	elsize int,
+	less func(a, b []byte) bool,
+	workDir string,
+	memBudget int,
+	fanIn int,
+	prog func(perc float64) bool,
+) error {
+	if elsize <= 0 {
+		panic("elsize <= 0")
+	}
+	if workDir == "" {
+		workDir = os.TempDir()
+	}
+	if memBudget <= 0 {
+		memBudget = defaultMemBudget
+	}
+	if fanIn < 2 {
+		fanIn = defaultFanIn
+	}
+	chunkRecs := memBudget / elsize
+	if chunkRecs < 1 {
+		chunkRecs = 1
+	}
+
+	rs := newRunSet(workDir)
+	defer rs.removeAll()
+
+	// When r is seekable (e.g. an *os.File), use its remaining size to
+	// weight the two phases by bytes processed. Otherwise the total size
+	// isn't known up front, so phase 1 only reports an estimate below 0.5
+	// (see below) and the merge phase reports the real progress.
+	var totalSize int64 = -1
+	if s, ok := r.(io.Seeker); ok {
+		if cur, cerr := s.Seek(0, io.SeekCurrent); cerr == nil {
+			if end, eerr := s.Seek(0, io.SeekEnd); eerr == nil {
+				totalSize = end - cur
+				if _, serr := s.Seek(cur, io.SeekStart); serr != nil {
+					return serr
+				}
+			}
+		}
+	}
+
+	// Phase 1: read the input in memBudget-sized chunks, sort each chunk
+	// in memory, and write it out as a sorted run.
+	buf := make([]byte, chunkRecs*elsize)
+	var genBytes, nitems, genChunks int64
+	for {
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 {
+			if n%elsize != 0 {
+				return fmt.Errorf(
+					"progsort: read %d bytes, not a multiple of elsize %d",
+					n, elsize)
+			}
+			chunk := buf[:n]
+			SortBytes(chunk, nil, elsize, less, nil)
+			if err := rs.writeRun(chunk); err != nil {
+				return err
+			}
+			genBytes += int64(n)
+			nitems += int64(n) / int64(elsize)
+			genChunks++
+			if prog != nil {
+				var p float64
+				if totalSize > 0 {
+					p = float64(genBytes) / float64(totalSize) * 0.5
+				} else {
+					// totalSize isn't known, so there's no meaningful
+					// percentage to report. Report a value that creeps
+					// towards, but never reaches, the midpoint as more
+					// chunks come in, so prog is still polled (and
+					// cancellation still checked) once per chunk instead
+					// of being skipped for the entire run-generation
+					// phase.
+					p = 0.5 * (1 - 1/float64(genChunks+1))
+				}
+				if !prog(p) {
+					return ErrCanceled
+				}
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+
+	// Phase 2: merge the runs, in passes of fanIn at a time, into w.
+	// Phase 1 always reports values up to 0.5 (reaching it only when
+	// totalSize is known), so mergeProg must always offset
+	// into the top half too, or progress would jump backward the instant
+	// the merge phase starts for non-seekable readers.
+	mergeProg := func(frac float64) bool {
+		if prog == nil {
+			return true
+		}
+		return prog(0.5 + frac*0.5)
+	}
+	if err := rs.mergeAll(elsize, less, w, fanIn, nitems, mergeProg); err != nil {
+		return err
+	}
+	if prog != nil {
+		prog(1)
+	}
+	return nil
+}
+
+// runSet manages the temporary run files created during an external sort,
+// so that they can all be cleaned up on error or cancellation.
+type runSet struct {
+	dir   string
+	seq   int
+	files []string
+}
+
+func newRunSet(dir string) *runSet {
+	return &runSet{dir: dir}
+}
+
+func (rs *runSet) tempFile(prefix string) (*os.File, error) {
+	rs.seq++
+	return os.CreateTemp(rs.dir, fmt.Sprintf("progsort-%s-%d-*.run", prefix, rs.seq))
+}
+
+func (rs *runSet) writeRun(data []byte) error {
+	f, err := rs.tempFile("run")
+	if err != nil {
+		return err
+	}
+	_, werr := f.Write(data)
+	cerr := f.Close()
+	if werr != nil {
+		os.Remove(f.Name())
+		return werr
+	}
+	if cerr != nil {
+		os.Remove(f.Name())
+		return cerr
+	}
+	rs.files = append(rs.files, f.Name())
+	return nil
+}
+
+func (rs *runSet) removeAll() {
+	for _, name := range rs.files {
+		os.Remove(name)
+	}
+	rs.files = nil
+}
+
+// consume removes names from disk and drops them from rs.files, so that a
+// later removeAll (e.g. on cancellation) doesn't try to remove them again.
+func (rs *runSet) consume(names []string) {
+	for _, n := range names {
+		os.Remove(n)
+	}
+	rm := make(map[string]bool, len(names))
+	for _, n := range names {
+		rm[n] = true
+	}
+	kept := rs.files[:0]
+	for _, f := range rs.files {
+		if !rm[f] {
+			kept = append(kept, f)
+		}
+	}
+	rs.files = kept
+}
+
+// mergeAll merges the runs in rs into w, fanning in at most fanIn runs at a
+// time and running extra passes when there are more runs than that. Every
+// intermediate run it creates is tracked in rs.files as soon as it's
+// created, so that a cancellation partway through a pass still leaves
+// nothing behind for the caller's deferred removeAll to clean up.
+func (rs *runSet) mergeAll(
+	elsize int,
+	less func(a, b []byte) bool,
+	w io.Writer,
+	fanIn int,
+	nitems int64,
+	prog func(frac float64) bool,
+) error {
+	if fanIn < 2 {
+		fanIn = 2
+	}
+	npasses := 1
+	for n := len(rs.files); n > fanIn; n = (n + fanIn - 1) / fanIn {
+		npasses++
+	}
+	totalWork := nitems * int64(npasses)
+	var done int64
+
+	for len(rs.files) > fanIn {
+		level := append([]string(nil), rs.files...)
+		var next []string
+		for i := 0; i < len(level); i += fanIn {
+			j := i + fanIn
+			if j > len(level) {
+				j = len(level)
+			}
+			batch := level[i:j]
+			out, err := rs.tempFile("merge")
+			if err != nil {
+				return err
+			}
+			rs.files = append(rs.files, out.Name())
+			err = mergeRuns(batch, elsize, less, out, &done, totalWork, prog)
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+			rs.consume(batch)
+			if err != nil {
+				return err
+			}
+			next = append(next, out.Name())
+		}
+		rs.files = next
+	}
+
+	level := rs.files
+	err := mergeRuns(level, elsize, less, w, &done, totalWork, prog)
+	rs.consume(level)
+	return err
+}
+
+// heapItem is one run's current record, ordered by recHeap.less.
+type heapItem struct {
+	rec []byte
+	run int
+}
+
+// recHeap is a min-heap of heapItems, used to perform the k-way merge.
+type recHeap struct {
+	items []heapItem
+	less  func(a, b []byte) bool
+}
+
+func (h *recHeap) Len() int           { return len(h.items) }
+func (h *recHeap) Less(i, j int) bool { return h.less(h.items[i].rec, h.items[j].rec) }
+func (h *recHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *recHeap) Push(x any) {
+	h.items = append(h.items, x.(heapItem))
+}
+
+func (h *recHeap) Pop() any {
+	old := h.items
+	n := len(old)
+	it := old[n-1]
+	h.items = old[:n-1]
+	return it
+}
+
+// mergeRuns performs a k-way merge of the runs at paths into w using a
+// min-heap keyed by less, buffering reads per run and writes to w.
+func mergeRuns(
+	paths []string,
+	elsize int,
+	less func(a, b []byte) bool,
+	w io.Writer,
+	done *int64,
+	totalWork int64,
+	prog func(frac float64) bool,
+) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	files := make([]*os.File, len(paths))
+	readers := make([]*bufio.Reader, len(paths))
+	for i, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			for _, f2 := range files[:i] {
+				f2.Close()
+			}
+			return err
+		}
+		files[i] = f
+		readers[i] = bufio.NewReaderSize(f, 1<<20)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	bw := bufio.NewWriterSize(w, 1<<20)
+	h := &recHeap{less: less}
+	for i, br := range readers {
+		rec := make([]byte, elsize)
+		if _, err := io.ReadFull(br, rec); err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return err
+		}
+		heap.Push(h, heapItem{rec: rec, run: i})
+	}
+
+	var scounter int64
+	for h.Len() > 0 {
+		it := heap.Pop(h).(heapItem)
+		if _, err := bw.Write(it.rec); err != nil {
+			return err
+		}
+		next := make([]byte, elsize)
+		if _, err := io.ReadFull(readers[it.run], next); err == nil {
+			heap.Push(h, heapItem{rec: next, run: it.run})
+		} else if err != io.EOF {
+			return err
+		}
+		scounter++
+		if scounter >= pchunk {
+			*done += scounter
+			scounter = 0
+			if prog != nil && !prog(float64(*done)/float64(totalWork)) {
+				return ErrCanceled
+			}
+		}
+	}
+	*done += scounter
+	if prog != nil && !prog(float64(*done)/float64(totalWork)) {
+		return ErrCanceled
+	}
+	return bw.Flush()
+}
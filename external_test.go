@@ -0,0 +1,161 @@
+package progsort
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func u64Less(a, b []byte) bool {
+	return binary.LittleEndian.Uint64(a) < binary.LittleEndian.Uint64(b)
+}
+
+func packU64s(items []int) []byte {
+	buf := make([]byte, len(items)*8)
+	for i, v := range items {
+		binary.LittleEndian.PutUint64(buf[i*8:], uint64(v))
+	}
+	return buf
+}
+
+func unpackU64s(data []byte) []int {
+	items := make([]int, len(data)/8)
+	for i := range items {
+		items[i] = int(binary.LittleEndian.Uint64(data[i*8:]))
+	}
+	return items
+}
+
+func TestSortReader(t *testing.T) {
+	const elsize = 8
+	const N = 50_000
+	buf := bytes.NewBuffer(packU64s(rand.Perm(N)))
+	var out bytes.Buffer
+	// A tiny memBudget forces many runs and multiple merge fan-in passes.
+	if err := SortReader(buf, &out, elsize, u64Less, t.TempDir(), elsize*37, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := unpackU64s(out.Bytes())
+	if len(got) != N || !sort.IntsAreSorted(got) {
+		t.Fatal("not sorted")
+	}
+}
+
+func TestSortFile(t *testing.T) {
+	const elsize = 8
+	const N = 20_000
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.bin")
+	if err := os.WriteFile(path, packU64s(rand.Perm(N)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SortFile(path, elsize, u64Less, dir, elsize*53, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := unpackU64s(data)
+	if len(got) != N || !sort.IntsAreSorted(got) {
+		t.Fatal("not sorted")
+	}
+}
+
+func TestSortReaderLarge(t *testing.T) {
+	const elsize = 8
+	const N = 3_000_000 // ~24MB of records
+	buf := bytes.NewBuffer(packU64s(rand.Perm(N)))
+	var out bytes.Buffer
+	// A small memBudget and fanIn force many runs and several merge
+	// fan-in passes.
+	if err := SortReader(buf, &out, elsize, u64Less, t.TempDir(), 1<<20, 4, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := unpackU64s(out.Bytes())
+	if len(got) != N || !sort.IntsAreSorted(got) {
+		t.Fatal("not sorted")
+	}
+}
+
+func TestSortReaderNonSeekableProgress(t *testing.T) {
+	const elsize = 8
+	const N = 200_000
+	// buf is a *bytes.Buffer, which is not an io.Seeker, so SortReader
+	// can't weight progress by bytes remaining in phase 1 and must fall
+	// back to its unweighted signal there instead.
+	buf := bytes.NewBuffer(packU64s(rand.Perm(N)))
+	var out bytes.Buffer
+	var last float64
+	err := SortReader(buf, &out, elsize, u64Less, t.TempDir(), elsize*1000, 0, func(perc float64) bool {
+		if perc < last {
+			t.Fatalf("percent went backward: %v -> %v", last, perc)
+		}
+		last = perc
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != 1 {
+		t.Fatalf("expected final percent of 1, got %v", last)
+	}
+	got := unpackU64s(out.Bytes())
+	if len(got) != N || !sort.IntsAreSorted(got) {
+		t.Fatal("not sorted")
+	}
+}
+
+func TestSortReaderCancelPromptly(t *testing.T) {
+	const elsize = 8
+	const N = 2_000_000
+	// buf is a *bytes.Buffer, which is not an io.Seeker, so SortReader
+	// can't weight progress by bytes remaining and must fall back to
+	// its unweighted phase-1 signal.
+	buf := bytes.NewBuffer(packU64s(rand.Perm(N)))
+	var out bytes.Buffer
+	var calls int
+	start := time.Now()
+	err := SortReader(buf, &out, elsize, u64Less, t.TempDir(), elsize*4000, 0, func(perc float64) bool {
+		calls++
+		return false
+	})
+	elapsed := time.Since(start)
+	if err != ErrCanceled {
+		t.Fatalf("expected ErrCanceled, got %v", err)
+	}
+	if calls == 0 {
+		t.Fatal("expected prog to be called")
+	}
+	// Canceling on the very first callback should abort during run
+	// generation, long before a full sort of N records would finish.
+	if elapsed > 200*time.Millisecond {
+		t.Fatalf("cancellation took too long: %v (calls=%d)", elapsed, calls)
+	}
+}
+
+func TestSortReaderCancel(t *testing.T) {
+	const elsize = 8
+	const N = 200_000
+	buf := bytes.NewBuffer(packU64s(rand.Perm(N)))
+	var out bytes.Buffer
+	dir := t.TempDir()
+	err := SortReader(buf, &out, elsize, u64Less, dir, elsize*1000, 0, func(perc float64) bool {
+		return perc < 0.25
+	})
+	if err != ErrCanceled {
+		t.Fatalf("expected ErrCanceled, got %v", err)
+	}
+	entries, rerr := os.ReadDir(dir)
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected temp files to be removed, found %d", len(entries))
+	}
+}
@@ -0,0 +1,66 @@
+package progsort
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSortWithOptionsProgress(t *testing.T) {
+	const N = 20_000
+	items := rand.Perm(N)
+	var last Progress
+	var calls int
+	swapped := SortWithOptions(items, nil, func(a, b int) bool { return a < b }, SortOptions{
+		Prog: func(p Progress) bool {
+			calls++
+			if p.Percent < last.Percent {
+				t.Fatal("percent out of order")
+			}
+			if p.ItemsMerged < last.ItemsMerged {
+				t.Fatal("items merged out of order")
+			}
+			if p.ItemsTotal <= 0 || p.TotalLevels <= 0 {
+				t.Fatal("expected positive totals")
+			}
+			last = p
+			return true
+		},
+	})
+	if swapped {
+		t.Fatal("items should hold the final sorted data")
+	}
+	if calls == 0 {
+		t.Fatal("expected at least one progress callback")
+	}
+	if last.Percent != 1 {
+		t.Fatalf("expected final percent of 1, got %v", last.Percent)
+	}
+	if !sort.IntsAreSorted(items) {
+		t.Fatal("not sorted")
+	}
+}
+
+func TestSortBytesWithOptionsCancel(t *testing.T) {
+	const N = 200_000
+	items := rand.Perm(N)
+	data := packU64s(items)
+	var calls int
+	var canceled bool
+	SortBytesWithOptions(data, nil, 8, u64Less, SortOptions{
+		Prog: func(p Progress) bool {
+			calls++
+			if p.Percent >= 0.5 {
+				canceled = true
+				return false
+			}
+			return true
+		},
+	})
+	if calls == 0 {
+		t.Fatal("expected at least one progress callback")
+	}
+	if !canceled {
+		t.Fatal("expected the sort to be canceled")
+	}
+}
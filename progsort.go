@@ -5,6 +5,7 @@
 package progsort
 
 import (
+	"context"
 	"math"
 	"runtime"
 	"sync"
@@ -12,6 +13,39 @@ import (
 	"time"
 )
 
+// Progress describes the state of an in-progress sort operation, passed
+// to the callback set on SortOptions.Prog.
+type Progress struct {
+	// Percent is the fraction of the sort completed, in the range
+	// [0.0,1.0].
+	Percent float64
+	// ItemsMerged is the approximate number of items processed so far,
+	// across all merge levels.
+	ItemsMerged int64
+	// ItemsTotal is the total number of items that will be processed
+	// across all merge levels.
+	ItemsTotal int64
+	// MergeLevel is the approximate merge level currently being worked on.
+	MergeLevel int
+	// TotalLevels is the total number of merge levels the sort will
+	// perform.
+	TotalLevels int
+	// Elapsed is the time spent sorting so far.
+	Elapsed time.Duration
+	// ETA is the estimated time remaining, based on a moving average of
+	// recent throughput. It's zero until there's enough data to estimate.
+	ETA time.Duration
+	// ItemsPerSec is a moving average of recent throughput.
+	ItemsPerSec float64
+}
+
+// SortOptions configures a call to SortWithOptions or SortBytesWithOptions.
+type SortOptions struct {
+	// Prog, when non-nil, is called periodically with the current
+	// Progress of the sort. Returning false cancels the sort early.
+	Prog func(p Progress) bool
+}
+
 // Sort data given the provided less function.
 //
 // The spare param is a slice that the caller can provide for helping with the
@@ -26,47 +60,53 @@ import (
 // the continual progress of the sort operation, which is a percentage between
 // the range [0.0,1.0]. Set prog to nil if progress monitoring is not needed.
 // Returning false from the prog function will cancel the sorting early.
+//
+// See SortWithOptions for a richer progress callback with throughput and
+// ETA information.
 func Sort[T any](
 	data []T,
 	spare []T,
 	less func(a, b T) bool,
 	prog func(prec float64) bool,
+) (swapped bool) {
+	var opts SortOptions
+	if prog != nil {
+		opts.Prog = func(p Progress) bool { return prog(p.Percent) }
+	}
+	return SortWithOptions(data, spare, less, opts)
+}
+
+// SortWithOptions behaves like Sort, but takes a SortOptions for richer
+// progress reporting via SortOptions.Prog instead of a plain percentage
+// callback.
+func SortWithOptions[T any](
+	data []T,
+	spare []T,
+	less func(a, b T) bool,
+	opts SortOptions,
 ) (swapped bool) {
 	var spared bool
 	if spare == nil {
 		spare = make([]T, len(data))
+		spared = true
 	}
 	if len(data) != len(spare) {
 		panic("len(active) != len(spare)")
 	}
-	nprocs := runtime.NumCPU()
+	pool := newChanPool(runtime.NumCPU())
 	var vprog int32
 	var vcancel int32
 	var vdone int32
 	var wg sync.WaitGroup
-	if prog != nil {
+	if opts.Prog != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			var lperc float64
-			for {
-				done := atomic.LoadInt32(&vdone) == 1
-				perc := float64(atomic.LoadInt32(&vprog)) / math.MaxInt32
-				if perc > lperc {
-					if !prog(perc) {
-						atomic.StoreInt32(&vcancel, 1)
-						break
-					}
-				}
-				if done {
-					break
-				}
-				time.Sleep(time.Second / 5)
-			}
-
+			runProgress(len(data), &vprog, &vdone, &vcancel, opts.Prog)
 		}()
 	}
-	swapped = mergeSort(data, spare, less, nprocs, &vprog, &vcancel)
+	swapped = mergeSort(data, spare, less, pool, 1, nil, &vprog, &vcancel)
+	pool.closeAndWait()
 	if swapped && spared {
 		copy(data, spare)
 		swapped = false
@@ -77,6 +117,262 @@ func Sort[T any](
 	return swapped
 }
 
+// runProgress polls vprog until vdone is set, translating the raw percent
+// into a Progress and invoking prog, setting vcancel if prog returns false.
+// It's shared by the generic and []byte sort entry points.
+func runProgress(nitems int, vprog, vdone, vcancel *int32, prog func(Progress) bool) {
+	runProgressLevels(nitems, calcMergeLevels(nitems), vprog, vdone, vcancel, prog)
+}
+
+// runProgressLevels is runProgress for a sort that performs nmlevels merge
+// levels, such as one presorted in base-run blocks via WithBaseRun.
+func runProgressLevels(nitems, nmlevels int, vprog, vdone, vcancel *int32, prog func(Progress) bool) {
+	itemsTotal := int64(nmlevels) * int64(nitems)
+	start := time.Now()
+	const emaAlpha = 0.3
+	var lperc float64
+	var emaRate float64
+	lastT := start
+	var lastItems int64
+	for {
+		done := atomic.LoadInt32(vdone) == 1
+		perc := float64(atomic.LoadInt32(vprog)) / math.MaxInt32
+		if perc > lperc {
+			now := time.Now()
+			itemsMerged := int64(perc * float64(itemsTotal))
+			if dt := now.Sub(lastT).Seconds(); dt > 0 {
+				rate := float64(itemsMerged-lastItems) / dt
+				if emaRate == 0 {
+					emaRate = rate
+				} else {
+					emaRate = emaAlpha*rate + (1-emaAlpha)*emaRate
+				}
+			}
+			lastT = now
+			lastItems = itemsMerged
+			var eta time.Duration
+			if emaRate > 0 {
+				remaining := itemsTotal - itemsMerged
+				eta = time.Duration(float64(remaining) / emaRate * float64(time.Second))
+			}
+			p := Progress{
+				Percent:     perc,
+				ItemsMerged: itemsMerged,
+				ItemsTotal:  itemsTotal,
+				MergeLevel:  int(perc * float64(nmlevels)),
+				TotalLevels: nmlevels,
+				Elapsed:     now.Sub(start),
+				ETA:         eta,
+				ItemsPerSec: emaRate,
+			}
+			lperc = perc
+			if !prog(p) {
+				atomic.StoreInt32(vcancel, 1)
+				break
+			}
+		}
+		if done {
+			break
+		}
+		time.Sleep(time.Second / 5)
+	}
+}
+
+// Pool submits work for execution. It lets many concurrent Sort-family
+// calls share one bounded set of goroutines via WithPool, instead of each
+// call spawning its own runtime.NumCPU() goroutines.
+type Pool interface {
+	// Submit queues fn to run on the pool. fn is executed exactly once.
+	Submit(fn func())
+}
+
+// chanPool is the Pool used when no pool is supplied via WithPool: n
+// goroutines reading off a buffered channel, the same scheme Sort has
+// always used internally.
+type chanPool struct {
+	c  chan func()
+	wg sync.WaitGroup
+}
+
+func newChanPool(n int) *chanPool {
+	if n < 1 {
+		n = 1
+	}
+	p := &chanPool{c: make(chan func(), n*16)}
+	p.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer p.wg.Done()
+			for fn := range p.c {
+				fn()
+			}
+		}()
+	}
+	return p
+}
+
+func (p *chanPool) Submit(fn func()) { p.c <- fn }
+
+func (p *chanPool) closeAndWait() {
+	close(p.c)
+	p.wg.Wait()
+}
+
+// BaseSort identifies the in-place algorithm used to presort the
+// contiguous base-run blocks configured via WithBaseRun, before the merge
+// phase begins.
+type BaseSort int
+
+const (
+	// InsertionSort presorts each base-run block with a simple insertion
+	// sort. It's the better choice for the small block sizes (dozens of
+	// items) WithBaseRun is typically configured with.
+	InsertionSort BaseSort = iota
+	// PDQSort presorts each base-run block with a quicksort variant that
+	// falls back to insertion sort on small ranges. It's a better choice
+	// than InsertionSort for larger block sizes.
+	PDQSort
+)
+
+// Option configures a call to SortContext.
+type Option func(*sortConfig)
+
+type sortConfig struct {
+	workers int
+	pool    Pool
+	baseRun *baseRunConfig
+	prog    func(p Progress) bool
+}
+
+// baseRunConfig holds the block size and algorithm configured via
+// WithBaseRun/WithBaseRunFunc. fn, when set, holds a
+// func(data []T, less func(a, b T) bool) for the T the sort was called
+// with; it's type-asserted back in resolveBaseSort.
+type baseRunConfig struct {
+	size int
+	algo BaseSort
+	fn   any
+}
+
+// WithBaseRun presorts data in contiguous blocks of size (32-64 is a
+// reasonable default) using algo before the merge phase begins, seeding
+// the merge loop at csize=size instead of csize=1. This closes much of
+// the gap with sort.Ints on small inputs (100-10,000 items), where
+// merge-sort's per-level overhead dominates.
+func WithBaseRun(size int, algo BaseSort) Option {
+	return func(c *sortConfig) { c.baseRun = &baseRunConfig{size: size, algo: algo} }
+}
+
+// WithBaseRunFunc behaves like WithBaseRun, but presorts each block with a
+// user-supplied function instead of InsertionSort or PDQSort.
+func WithBaseRunFunc[T any](size int, fn func(data []T, less func(a, b T) bool)) Option {
+	return func(c *sortConfig) { c.baseRun = &baseRunConfig{size: size, fn: fn} }
+}
+
+// WithWorkers sets the number of goroutines used to perform the sort when
+// no pool is supplied via WithPool. Defaults to runtime.NumCPU().
+func WithWorkers(n int) Option {
+	return func(c *sortConfig) { c.workers = n }
+}
+
+// WithPool supplies a Pool for executing sort work, letting many
+// concurrent sort calls share one bounded pool of goroutines instead of
+// each spawning its own.
+func WithPool(p Pool) Option {
+	return func(c *sortConfig) { c.pool = p }
+}
+
+// WithProg sets a callback invoked periodically with the current Progress
+// of the sort, same as SortOptions.Prog. Returning false cancels the sort
+// early, same as canceling ctx; SortContext returns ErrCanceled in that
+// case instead of ctx.Err().
+func WithProg(prog func(p Progress) bool) Option {
+	return func(c *sortConfig) { c.prog = prog }
+}
+
+// SortContext behaves like Sort, but aborts as soon as ctx is canceled,
+// returning ctx.Err() instead of continuing to completion or silently
+// producing partial data.
+//
+// By default SortContext spawns its own runtime.NumCPU() goroutines for
+// the duration of the call, same as Sort. Use WithWorkers to change that
+// count, or WithPool to share a bounded Pool across many sort calls
+// instead.
+func SortContext[T any](
+	ctx context.Context,
+	data []T,
+	spare []T,
+	less func(a, b T) bool,
+	opts ...Option,
+) (swapped bool, err error) {
+	cfg := sortConfig{workers: runtime.NumCPU()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var spared bool
+	if spare == nil {
+		spare = make([]T, len(data))
+		spared = true
+	}
+	if len(data) != len(spare) {
+		panic("len(active) != len(spare)")
+	}
+
+	pool := cfg.pool
+	var owned *chanPool
+	if pool == nil {
+		owned = newChanPool(cfg.workers)
+		pool = owned
+	}
+
+	baseSize := 1
+	var baseSortFn func(data []T, lo, hi int)
+	if cfg.baseRun != nil && cfg.baseRun.size > 1 {
+		baseSize = cfg.baseRun.size
+		baseSortFn = resolveBaseSort(cfg.baseRun, less)
+	}
+
+	var vprog, vcancel, vdone int32
+	var wg sync.WaitGroup
+	if cfg.prog != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runProgressLevels(len(data), calcMergeLevelsFrom(len(data), baseSize),
+				&vprog, &vdone, &vcancel, cfg.prog)
+		}()
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			atomic.StoreInt32(&vcancel, 1)
+		case <-stop:
+		}
+	}()
+
+	swapped = mergeSort(data, spare, less, pool, baseSize, baseSortFn, &vprog, &vcancel)
+	close(stop)
+	atomic.StoreInt32(&vprog, math.MaxInt32)
+	atomic.StoreInt32(&vdone, 1)
+	wg.Wait()
+	if owned != nil {
+		owned.closeAndWait()
+	}
+	if swapped && spared {
+		copy(data, spare)
+		swapped = false
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return swapped, ctxErr
+	}
+	if atomic.LoadInt32(&vcancel) != 0 {
+		return swapped, ErrCanceled
+	}
+	return swapped, nil
+}
+
 const pchunk = 1024
 
 type mergeGroup struct {
@@ -105,14 +401,22 @@ func addSteps(
 func mergeSort[T any](
 	active, spare []T,
 	less func(a, b T) bool,
-	nprocs int,
+	pool Pool,
+	baseSize int,
+	baseSortFn func(data []T, lo, hi int),
 	prog *int32,
 	cancel *int32,
 ) (swapped bool) {
 
 	start, end := 0, len(active)
-	nmlevels := calcMergeLevels(end - start)
+	if baseSize < 1 {
+		baseSize = 1
+	}
+	nmlevels := calcMergeLevelsFrom(end-start, baseSize)
 	nsteps := int64(nmlevels * (end - start))
+	if baseSize > 1 {
+		nsteps += int64(end - start)
+	}
 	var smu sync.Mutex
 	var steps int64
 
@@ -121,42 +425,51 @@ func mergeSort[T any](
 	datas[1] = spare
 
 	var wg sync.WaitGroup
-	mergeC := make(chan mergeGroup, nprocs*16)
-	defer func() {
-		close(mergeC)
-		wg.Wait()
-	}()
-	for g := 0; g < nprocs; g++ {
-		go func() {
+	submit := func(m mergeGroup) {
+		wg.Add(1)
+		pool.Submit(func() {
+			defer wg.Done()
 			var scounter int64
-			for m := range mergeC {
-				for i := 0; i < m.count; i++ {
-					var ok bool
-					scounter, ok = mergeSortUnit(
-						m.i1, m.i1+m.z1, m.i2, m.i2+m.z2,
-						active, spare, less, prog, cancel,
-						&smu, &steps, nsteps,
-						scounter,
-					)
-					if !ok {
-						break
-					}
-					m.i1 += m.z1 + m.z2
-					m.i2 = m.i1 + m.z1
-				}
-				if scounter > pchunk {
-					if !addSteps(scounter, prog, cancel, &smu, &steps, nsteps) {
-						break
-					}
-					scounter = 0
+			for i := 0; i < m.count; i++ {
+				var ok bool
+				scounter, ok = mergeSortUnit(
+					m.i1, m.i1+m.z1, m.i2, m.i2+m.z2,
+					active, spare, less, prog, cancel,
+					&smu, &steps, nsteps,
+					scounter,
+				)
+				if !ok {
+					break
 				}
-				wg.Add(-m.count)
+				m.i1 += m.z1 + m.z2
+				m.i2 = m.i1 + m.z1
 			}
-		}()
+			if scounter > 0 {
+				addSteps(scounter, prog, cancel, &smu, &steps, nsteps)
+			}
+		})
+	}
+
+	if baseSize > 1 && atomic.LoadInt32(cancel) == 0 {
+		var bwg sync.WaitGroup
+		for lo := start; lo < end; lo += baseSize {
+			hi := lo + baseSize
+			if hi > end {
+				hi = end
+			}
+			lo, hi := lo, hi
+			bwg.Add(1)
+			pool.Submit(func() {
+				defer bwg.Done()
+				baseSortFn(active, lo, hi)
+			})
+		}
+		bwg.Wait()
+		addSteps(int64(end-start), prog, cancel, &smu, &steps, nsteps)
 	}
 
 	var gm mergeGroup
-	csize := 1
+	csize := baseSize
 	mlevel := 0
 	for ; mlevel < nmlevels; mlevel++ {
 		active = datas[mlevel&1]
@@ -175,17 +488,15 @@ func mergeSort[T any](
 			}
 			m := mergeGroup{i1: i1, z1: size1, i2: i2, z2: size2}
 			if mlevel > 7 || size1 != csize || size2 != csize {
-				wg.Add(1)
 				m.count = 1
-				mergeC <- m
+				submit(m)
 			} else {
 				if gm.count == 0 {
 					gm = m
 				}
 				gm.count++
 				if gm.count == 256>>mlevel {
-					wg.Add(gm.count)
-					mergeC <- gm
+					submit(gm)
 					gm.count = 0
 				}
 			}
@@ -195,8 +506,7 @@ func mergeSort[T any](
 			i += size1 + size2
 		}
 		if gm.count > 0 {
-			wg.Add(gm.count)
-			mergeC <- gm
+			submit(gm)
 			gm.count = 0
 		}
 		wg.Wait()
@@ -301,3 +611,84 @@ func calcMergeLevels(count int) int {
 	}
 	return levels
 }
+
+// calcMergeLevelsFrom is calcMergeLevels, but for merging runs that start
+// out already presorted in contiguous blocks of blockSize, as configured
+// via WithBaseRun, instead of individual items.
+func calcMergeLevelsFrom(count, blockSize int) int {
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	nblocks := (count + blockSize - 1) / blockSize
+	return calcMergeLevels(nblocks)
+}
+
+// resolveBaseSort returns the in-place sort function configured by
+// rc, type-asserting rc.fn back to the T that less was called with when
+// WithBaseRunFunc was used.
+func resolveBaseSort[T any](rc *baseRunConfig, less func(a, b T) bool) func(data []T, lo, hi int) {
+	if rc.fn != nil {
+		fn := rc.fn.(func(data []T, less func(a, b T) bool))
+		return func(data []T, lo, hi int) { fn(data[lo:hi], less) }
+	}
+	switch rc.algo {
+	case PDQSort:
+		return func(data []T, lo, hi int) { pdqSortRange(data, lo, hi, less) }
+	default:
+		return func(data []T, lo, hi int) { insertionSortRange(data, lo, hi, less) }
+	}
+}
+
+// insertionSortRange sorts data[lo:hi] in place using insertion sort.
+func insertionSortRange[T any](data []T, lo, hi int, less func(a, b T) bool) {
+	for i := lo + 1; i < hi; i++ {
+		for j := i; j > lo && less(data[j], data[j-1]); j-- {
+			data[j], data[j-1] = data[j-1], data[j]
+		}
+	}
+}
+
+// pdqSortRange sorts data[lo:hi] in place using a median-of-three
+// quicksort that falls back to insertionSortRange on small ranges. It's a
+// simplified stand-in for a true pattern-defeating quicksort, good enough
+// for presorting the small base-run blocks configured via WithBaseRun.
+func pdqSortRange[T any](data []T, lo, hi int, less func(a, b T) bool) {
+	for hi-lo > 12 {
+		mid := lo + (hi-lo)/2
+		if less(data[mid], data[lo]) {
+			data[mid], data[lo] = data[lo], data[mid]
+		}
+		if less(data[hi-1], data[lo]) {
+			data[hi-1], data[lo] = data[lo], data[hi-1]
+		}
+		if less(data[hi-1], data[mid]) {
+			data[hi-1], data[mid] = data[mid], data[hi-1]
+		}
+		pivot := data[mid]
+		data[mid], data[hi-2] = data[hi-2], data[mid]
+		i, j := lo, hi-2
+		for {
+			i++
+			for i < hi && less(data[i], pivot) {
+				i++
+			}
+			j--
+			for j > lo && less(pivot, data[j]) {
+				j--
+			}
+			if i >= j {
+				break
+			}
+			data[i], data[j] = data[j], data[i]
+		}
+		data[hi-2], data[i] = data[i], data[hi-2]
+		if i-lo < hi-i {
+			pdqSortRange(data, lo, i, less)
+			lo = i + 1
+		} else {
+			pdqSortRange(data, i+1, hi, less)
+			hi = i
+		}
+	}
+	insertionSortRange(data, lo, hi, less)
+}
@@ -2,12 +2,10 @@ package progsort
 
 import (
 	"fmt"
-	"math"
 	"math/rand"
 	"os"
 	"sort"
 	"strconv"
-	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -37,34 +35,16 @@ func TestProgSort(t *testing.T) {
 			cancelEarly = rand.Int()%5 == 0
 		}
 		items := rand.Perm(N)
-		final := make([]int, N)
-		var prog int32
-		var cancel int32
-		done := make(chan bool, 1)
-		go func() {
-			swapped := Sort(items, func(a, b int) bool {
-				return a < b
-			}, 0, final, &prog, &cancel)
-			if swapped {
-				items, final, swapped = final, items, !swapped
-			}
-			done <- true
-		}()
 		var prev float64
-		for {
-			p := float64(atomic.LoadInt32(&prog)) / math.MaxInt32
+		Sort(items, nil, func(a, b int) bool {
+			return a < b
+		}, func(p float64) bool {
 			if p < prev {
 				t.Fatal("out of order")
 			}
-			if p > 0.5 && cancelEarly {
-				atomic.StoreInt32(&cancel, 1)
-				break
-			}
-			if p == 1 {
-				break
-			}
-		}
-		<-done
+			prev = p
+			return !(p > 0.5 && cancelEarly)
+		})
 		if !sort.IntsAreSorted(items) {
 			if !cancelEarly {
 				t.Fatal("not sorted")
@@ -126,7 +106,7 @@ func benchInts(b *testing.B, N int) {
 	items := rand.Perm(N)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		Sort(items, func(a, b int) bool { return a < b }, 0, nil, nil, nil)
+		Sort(items, nil, func(a, b int) bool { return a < b }, nil)
 	}
 }
 
@@ -9,7 +9,6 @@ import (
 	"runtime"
 	"sync"
 	"sync/atomic"
-	"time"
 )
 
 // SortBytes data given the provided less function.
@@ -26,12 +25,32 @@ import (
 // the continual progress of the sort operation, which is a percentage between
 // the range [0.0,1.0]. Set prog to nil if progress monitoring is not needed.
 // Returning false from the prog function will cancel the sorting early.
+//
+// See SortBytesWithOptions for a richer progress callback with throughput
+// and ETA information.
 func SortBytes(
 	data []byte,
 	spare []byte,
 	elsize int,
 	less func(a, b []byte) bool,
 	prog func(prec float64) bool,
+) (swapped bool) {
+	var opts SortOptions
+	if prog != nil {
+		opts.Prog = func(p Progress) bool { return prog(p.Percent) }
+	}
+	return SortBytesWithOptions(data, spare, elsize, less, opts)
+}
+
+// SortBytesWithOptions behaves like SortBytes, but takes a SortOptions for
+// richer progress reporting via SortOptions.Prog instead of a plain
+// percentage callback.
+func SortBytesWithOptions(
+	data []byte,
+	spare []byte,
+	elsize int,
+	less func(a, b []byte) bool,
+	opts SortOptions,
 ) (swapped bool) {
 	if len(data)%elsize != 0 {
 		panic("len(data)%elsize != 0")
@@ -39,40 +58,28 @@ func SortBytes(
 	var spared bool
 	if spare == nil {
 		spare = make([]byte, len(data))
+		spared = true
 	}
 	if len(data) != len(spare) {
 		panic("len(active) != len(spare)")
 	}
-	nprocs := runtime.NumCPU()
+	nitems := len(data) / elsize
+	pool := newChanPool(runtime.NumCPU())
 	var vprog int32
 	var vcancel int32
 	var vdone int32
 	var wg sync.WaitGroup
-	if prog != nil {
+	if opts.Prog != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			var lperc float64
-			for {
-				done := atomic.LoadInt32(&vdone) == 1
-				perc := float64(atomic.LoadInt32(&vprog)) / math.MaxInt32
-				if perc > lperc {
-					if !prog(perc) {
-						atomic.StoreInt32(&vcancel, 1)
-						break
-					}
-				}
-				if done {
-					break
-				}
-				time.Sleep(time.Second / 5)
-			}
-
+			runProgress(nitems, &vprog, &vdone, &vcancel, opts.Prog)
 		}()
 	}
 	swapped = mergeSortBytes(data, spare,
-		len(data)/elsize, elsize, nprocs,
+		nitems, elsize, pool,
 		less, &vprog, &vcancel)
+	pool.closeAndWait()
 	if swapped && spared {
 		copy(data, spare)
 		swapped = false
@@ -88,7 +95,7 @@ func mergeSortBytes(
 	spare []byte,
 	nitems int,
 	elsize int,
-	nprocs int,
+	pool Pool,
 	less func(a, b []byte) bool,
 	prog *int32,
 	cancel *int32,
@@ -105,38 +112,29 @@ func mergeSortBytes(
 	datas[1] = spare
 
 	var wg sync.WaitGroup
-	mergeC := make(chan mergeGroup, nprocs*16)
-	defer func() {
-		close(mergeC)
-		wg.Wait()
-	}()
-	for g := 0; g < nprocs; g++ {
-		go func() {
+	submit := func(m mergeGroup) {
+		wg.Add(1)
+		pool.Submit(func() {
+			defer wg.Done()
 			var scounter int64
-			for m := range mergeC {
-				for i := 0; i < m.count; i++ {
-					var ok bool
-					scounter, ok = mergeSortUnitBytes(
-						m.i1, m.i1+m.z1, m.i2, m.i2+m.z2,
-						active, spare, nitems, elsize, less, prog, cancel,
-						&smu, &steps, nsteps,
-						scounter,
-					)
-					if !ok {
-						break
-					}
-					m.i1 += m.z1 + m.z2
-					m.i2 = m.i1 + m.z1
-				}
-				if scounter > pchunk {
-					if !addSteps(scounter, prog, cancel, &smu, &steps, nsteps) {
-						break
-					}
-					scounter = 0
+			for i := 0; i < m.count; i++ {
+				var ok bool
+				scounter, ok = mergeSortUnitBytes(
+					m.i1, m.i1+m.z1, m.i2, m.i2+m.z2,
+					active, spare, nitems, elsize, less, prog, cancel,
+					&smu, &steps, nsteps,
+					scounter,
+				)
+				if !ok {
+					break
 				}
-				wg.Add(-m.count)
+				m.i1 += m.z1 + m.z2
+				m.i2 = m.i1 + m.z1
 			}
-		}()
+			if scounter > 0 {
+				addSteps(scounter, prog, cancel, &smu, &steps, nsteps)
+			}
+		})
 	}
 
 	var gm mergeGroup
@@ -159,17 +157,15 @@ func mergeSortBytes(
 			}
 			m := mergeGroup{i1: i1, z1: size1, i2: i2, z2: size2}
 			if mlevel > 7 || size1 != csize || size2 != csize {
-				wg.Add(1)
 				m.count = 1
-				mergeC <- m
+				submit(m)
 			} else {
 				if gm.count == 0 {
 					gm = m
 				}
 				gm.count++
 				if gm.count == 256>>mlevel {
-					wg.Add(gm.count)
-					mergeC <- gm
+					submit(gm)
 					gm.count = 0
 				}
 			}
@@ -179,8 +175,7 @@ func mergeSortBytes(
 			i += size1 + size2
 		}
 		if gm.count > 0 {
-			wg.Add(gm.count)
-			mergeC <- gm
+			submit(gm)
 			gm.count = 0
 		}
 		wg.Wait()